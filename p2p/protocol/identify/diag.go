@@ -0,0 +1,280 @@
+package identify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/helpers"
+	"github.com/libp2p/go-libp2p-core/metrics"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// DiagID is the protocol.ID of the network diagnostics protocol. It rides
+// on top of identify: a peer is only asked for diagnostics once identify
+// has confirmed it supports DiagID.
+const DiagID = "/ipfs/diag/net/1.0.0"
+
+// diagSeenTTL bounds how long we remember a request id for deduplication
+// purposes, so the seen-set doesn't grow without bound.
+const diagSeenTTL = time.Minute
+
+// maxDiagTTL caps how many hops a single NetDiagnostics walk may forward
+// through, regardless of what a requester asks for. Without this, a peer
+// requesting (or forwarding with) an inflated TTL could fan a single
+// request out across the whole reachable graph.
+const maxDiagTTL = 4
+
+// DiagConnInfo describes one connection as seen by the peer reporting it.
+type DiagConnInfo struct {
+	Peer     peer.ID
+	BytesIn  uint64
+	BytesOut uint64
+	RTT      time.Duration
+}
+
+// DiagInfo is one peer's contribution to a NetDiagnostics walk: who it is,
+// how long it's been up, and what it's connected to.
+type DiagInfo struct {
+	ID           peer.ID
+	AgentVersion string
+	Uptime       time.Duration
+	Conns        []DiagConnInfo
+}
+
+// diagRequest is the wire format of a diagnostics request. ReqID lets
+// peers dedupe a request they've already seen and forwarded; TTL is a
+// hop-count that's decremented on every forward and stops the walk at 0.
+type diagRequest struct {
+	ReqID string
+	TTL   int
+}
+
+// diagResponse carries the responding peer's own DiagInfo along with
+// whatever its neighbors reported back, aggregated along the reverse path.
+type diagResponse struct {
+	ReqID string
+	Infos []*DiagInfo
+}
+
+// bandwidthReporter is implemented by hosts that track bandwidth usage
+// per-peer (e.g. basichost.BasicHost when configured with a
+// metrics.Reporter). It's checked via a type-assertion on ids.Host rather
+// than imported directly, to avoid a dependency cycle with p2p/host/basic.
+type bandwidthReporter interface {
+	GetBandwidthForPeer(p peer.ID) metrics.Stats
+}
+
+// NetDiagnostics walks the peer graph reachable from the local host,
+// collecting a DiagInfo from every connected peer that identifies support
+// for DiagID, and from every peer reachable by forwarding through them up
+// to ttl hops. It returns as soon as all direct requests complete or
+// settle; a peer that never responds simply doesn't contribute past its
+// point in the graph.
+func (ids *IDService) NetDiagnostics(ctx context.Context, ttl int) ([]*DiagInfo, error) {
+	if ttl > maxDiagTTL {
+		ttl = maxDiagTTL
+	}
+
+	reqID, err := newDiagReqID()
+	if err != nil {
+		return nil, err
+	}
+	ids.markDiagSeen(reqID)
+
+	infos := []*DiagInfo{ids.localDiagInfo()}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, p := range ids.Host.Network().Peers() {
+		for _, c := range ids.Host.Network().ConnsToPeer(p) {
+			select {
+			case <-ids.IdentifyWait(c):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if sup, err := ids.Host.Peerstore().SupportsProtocols(p, DiagID); err != nil || len(sup) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			remote, err := ids.requestDiag(ctx, p, reqID, ttl)
+			if err != nil {
+				log.Debugf("net diagnostics request to %s failed: %s", p, err)
+				return
+			}
+			mu.Lock()
+			infos = append(infos, remote...)
+			mu.Unlock()
+		}(p)
+	}
+
+	wg.Wait()
+	return infos, nil
+}
+
+// requestDiag opens a DiagID stream to p and exchanges a single
+// request/response.
+func (ids *IDService) requestDiag(ctx context.Context, p peer.ID, reqID string, ttl int) ([]*DiagInfo, error) {
+	s, err := ids.Host.NewStream(network.WithNoDial(ctx, string(DiagID)), p, DiagID)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.FullClose(s)
+
+	if err := json.NewEncoder(s).Encode(diagRequest{ReqID: reqID, TTL: ttl}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	var resp diagResponse
+	if err := json.NewDecoder(s).Decode(&resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	return resp.Infos, nil
+}
+
+// diagHandler answers an incoming diagnostics request: it reports on
+// itself, and, while TTL remains, forwards the request to its own
+// DiagID-supporting neighbors (other than the requester) and folds their
+// answers in before replying.
+//
+// To keep DiagID from being usable as an amplification vector, it only
+// answers peers we've already identified, and clamps the requester-supplied
+// TTL to maxDiagTTL regardless of what's asked for.
+func (ids *IDService) diagHandler(s network.Stream) {
+	defer helpers.FullClose(s)
+	c := s.Conn()
+
+	if !ids.isIdentified(c.RemotePeer()) {
+		log.Debugf("rejecting net diagnostics request from unidentified peer %s", c.RemotePeer())
+		s.Reset()
+		return
+	}
+
+	var req diagRequest
+	if err := json.NewDecoder(s).Decode(&req); err != nil {
+		log.Debugf("error reading net diagnostics request from %s: %s", c.RemotePeer(), err)
+		s.Reset()
+		return
+	}
+	if req.TTL > maxDiagTTL {
+		req.TTL = maxDiagTTL
+	}
+
+	infos := []*DiagInfo{ids.localDiagInfo()}
+
+	if req.TTL > 0 && ids.markDiagSeen(req.ReqID) {
+		fctx, cancel := context.WithTimeout(ids.ctx, 10*time.Second)
+		defer cancel()
+
+		for _, p := range ids.Host.Network().Peers() {
+			if p == c.RemotePeer() {
+				continue
+			}
+			if sup, err := ids.Host.Peerstore().SupportsProtocols(p, DiagID); err != nil || len(sup) == 0 {
+				continue
+			}
+			remote, err := ids.requestDiag(fctx, p, req.ReqID, req.TTL-1)
+			if err != nil {
+				log.Debugf("net diagnostics forward to %s failed: %s", p, err)
+				continue
+			}
+			infos = append(infos, remote...)
+		}
+	}
+
+	if err := json.NewEncoder(s).Encode(diagResponse{ReqID: req.ReqID, Infos: infos}); err != nil {
+		log.Debugf("error writing net diagnostics response to %s: %s", c.RemotePeer(), err)
+		s.Reset()
+	}
+}
+
+// isIdentified reports whether we've already identified p, i.e. whether
+// identify has recorded p as supporting the identify protocol (ID)
+// itself. diagHandler uses this so DiagID can't be used to get an unidentified (and thus untrusted)
+// peer an answer or a forwarded request.
+func (ids *IDService) isIdentified(p peer.ID) bool {
+	sup, err := ids.Host.Peerstore().SupportsProtocols(p, ID)
+	return err == nil && len(sup) > 0
+}
+
+// localDiagInfo builds this host's own DiagInfo from its current
+// connections.
+func (ids *IDService) localDiagInfo() *DiagInfo {
+	conns := ids.Host.Network().Conns()
+
+	info := &DiagInfo{
+		ID:           ids.Host.ID(),
+		AgentVersion: ids.UserAgent,
+		Uptime:       time.Since(ids.started),
+		Conns:        make([]DiagConnInfo, 0, len(conns)),
+	}
+
+	reporter, _ := ids.Host.(bandwidthReporter)
+
+	for _, c := range conns {
+		ci := DiagConnInfo{
+			Peer: c.RemotePeer(),
+			RTT:  ids.Host.Peerstore().LatencyEWMA(c.RemotePeer()),
+		}
+		if reporter != nil {
+			stats := reporter.GetBandwidthForPeer(c.RemotePeer())
+			ci.BytesIn = uint64(stats.TotalIn)
+			ci.BytesOut = uint64(stats.TotalOut)
+		}
+		info.Conns = append(info.Conns, ci)
+	}
+
+	return info
+}
+
+// markDiagSeen records reqID as seen and reports whether this was the
+// first time (i.e. whether the caller should act on the request rather
+// than just answering for itself). It also prunes entries older than
+// diagSeenTTL.
+func (ids *IDService) markDiagSeen(reqID string) bool {
+	now := time.Now()
+
+	ids.diagMu.Lock()
+	defer ids.diagMu.Unlock()
+
+	if ids.diagSeen == nil {
+		ids.diagSeen = make(map[string]time.Time)
+	}
+	for id, seenAt := range ids.diagSeen {
+		if now.Sub(seenAt) > diagSeenTTL {
+			delete(ids.diagSeen, id)
+		}
+	}
+
+	if _, found := ids.diagSeen[reqID]; found {
+		return false
+	}
+	ids.diagSeen[reqID] = now
+	return true
+}
+
+// newDiagReqID generates a random request id for a NetDiagnostics walk.
+func newDiagReqID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MarshalNetDiagnostics renders the result of NetDiagnostics as indented
+// JSON, so external tools can render the peer graph.
+func MarshalNetDiagnostics(infos []*DiagInfo) ([]byte, error) {
+	return json.MarshalIndent(infos, "", "  ")
+}
@@ -1,10 +1,13 @@
 package identify
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ic "github.com/libp2p/go-libp2p-core/crypto"
@@ -64,9 +67,9 @@ const transientTTL = 10 * time.Second
 // useful information about the local peer. A sort of hello.
 //
 // The IDService sends:
-//  * Our IPFS Protocol Version
-//  * Our IPFS Agent Version
-//  * Our public Listen Addresses
+//   - Our IPFS Protocol Version
+//   - Our IPFS Agent Version
+//   - Our public Listen Addresses
 type IDService struct {
 	Host      host.Host
 	UserAgent string
@@ -87,6 +90,33 @@ type IDService struct {
 	// our own observed addresses.
 	observedAddrs *ObservedAddrManager
 
+	// strictObservedAddrs gates whether observed addresses reported by
+	// remote peers must match one of our own interface listen addresses
+	// (by transport and port) before we'll record them.
+	strictObservedAddrs bool
+	// numObservedAddrsDropped counts observations rejected by the
+	// strictObservedAddrs check, for operators diagnosing NAT detection.
+	numObservedAddrsDropped uint64
+
+	// requireSignedIdentify rejects Identify/Push/Delta messages that lack
+	// a signature (or a known key to check it with), instead of tolerating
+	// them. A signature that is present and fails to verify is rejected
+	// either way.
+	requireSignedIdentify bool
+
+	// started records when this IDService came up, for NetDiagnostics' Uptime.
+	started time.Time
+
+	// diagMu guards diagSeen, the set of NetDiagnostics request ids this
+	// peer has already handled, used to dedupe forwarded requests.
+	diagMu   sync.Mutex
+	diagSeen map[string]time.Time
+
+	// gossip holds the pubsub topic/subscription used to publish and
+	// consume Identify updates when WithGossipIdentify is set; nil
+	// otherwise.
+	gossip *gossipIdentify
+
 	subscription event.Subscription
 	emitters     struct {
 		evtPeerProtocolsUpdated        event.Emitter
@@ -98,7 +128,7 @@ type IDService struct {
 // NewIDService constructs a new *IDService and activates it by
 // attaching its stream handler to the given host.Host.
 func NewIDService(h host.Host, opts ...Option) *IDService {
-	var cfg config
+	cfg := config{strictObservedAddrs: true}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
@@ -113,10 +143,14 @@ func NewIDService(h host.Host, opts ...Option) *IDService {
 		Host:      h,
 		UserAgent: userAgent,
 
-		ctx:           hostCtx,
-		ctxCancel:     cancel,
-		conns:         make(map[network.Conn]chan struct{}),
-		observedAddrs: NewObservedAddrManager(hostCtx, h),
+		ctx:                   hostCtx,
+		ctxCancel:             cancel,
+		conns:                 make(map[network.Conn]chan struct{}),
+		observedAddrs:         NewObservedAddrManager(hostCtx, h),
+		strictObservedAddrs:   cfg.strictObservedAddrs,
+		requireSignedIdentify: cfg.requireSignedIdentify,
+		started:               time.Now(),
+		diagSeen:              make(map[string]time.Time),
 	}
 
 	// handle local protocol handler updates, and push deltas to peers.
@@ -142,9 +176,16 @@ func NewIDService(h host.Host, opts ...Option) *IDService {
 		log.Warningf("identify service not emitting identification failed events; err: %s", err)
 	}
 
+	if gossip, err := setupGossipIdentify(s, &cfg); err != nil {
+		log.Warningf("identify service not set up for gossip identify; err: %s", err)
+	} else {
+		s.gossip = gossip
+	}
+
 	h.SetStreamHandler(ID, s.requestHandler)
 	h.SetStreamHandler(IDPush, s.pushHandler)
 	h.SetStreamHandler(IDDelta, s.deltaHandler)
+	h.SetStreamHandler(DiagID, s.diagHandler)
 	h.Network().Notify((*netNotifiee)(s))
 	return s
 }
@@ -268,7 +309,7 @@ func (ids *IDService) identifyConn(c network.Conn, signal chan struct{}) {
 		return
 	}
 
-	ids.responseHandler(s)
+	err = ids.responseHandler(s)
 }
 
 func (ids *IDService) requestHandler(s network.Stream) {
@@ -283,7 +324,11 @@ func (ids *IDService) requestHandler(s network.Stream) {
 	log.Debugf("%s sent message to %s %s", ID, c.RemotePeer(), c.RemoteMultiaddr())
 }
 
-func (ids *IDService) responseHandler(s network.Stream) {
+// responseHandler reads an Identify message off s and applies it, and
+// returns any error encountered reading or rejecting it. Callers that rely
+// on the outcome (e.g. identifyConn, to decide which event to emit) must
+// check this return value rather than assuming success.
+func (ids *IDService) responseHandler(s network.Stream) error {
 	c := s.Conn()
 
 	r := ggio.NewDelimitedReader(s, 2048)
@@ -291,13 +336,18 @@ func (ids *IDService) responseHandler(s network.Stream) {
 	if err := r.ReadMsg(&mes); err != nil {
 		log.Warning("error reading identify message: ", err)
 		s.Reset()
-		return
+		return err
 	}
 
-	defer func() { go helpers.FullClose(s) }()
-
 	log.Debugf("%s received message from %s %s", s.Protocol(), c.RemotePeer(), c.RemoteMultiaddr())
-	ids.consumeMessage(&mes, c)
+	if err := ids.consumeMessage(&mes, c); err != nil {
+		log.Debugf("%s rejected message from %s: %s", s.Protocol(), c.RemotePeer(), err)
+		s.Reset()
+		return err
+	}
+
+	go helpers.FullClose(s)
+	return nil
 }
 
 func (ids *IDService) broadcast(proto protocol.ID, payloadWriter func(s network.Stream)) {
@@ -366,14 +416,28 @@ func (ids *IDService) populateMessage(mes *pb.Identify, c network.Conn) {
 		mes.Protocols[i] = p
 	}
 
+	// set the transport and security capabilities this node supports, so
+	// peers can prefer dialing us over a transport we both support (e.g.
+	// QUIC) without needing a separate probing dial.
+	mes.Transports = ids.localTransports()
+	mes.SecurityProtocols = ids.localSecurityProtocols()
+
 	// observed address so other side is informed of their
-	// "public" address, at least in relation to us.
-	mes.ObservedAddr = c.RemoteMultiaddr().Bytes()
+	// "public" address, at least in relation to us. There's no single
+	// "other side" for a message with no connection behind it (e.g. one
+	// published over the gossip topic), so c may be nil; in that case we
+	// can't tell whether we're talking to a loopback peer, so default to
+	// filtering loopback listen addrs out rather than leaking them to
+	// every subscriber of the topic.
+	viaLoopback := false
+	if c != nil {
+		mes.ObservedAddr = c.RemoteMultiaddr().Bytes()
+		// Note: LocalMultiaddr is sometimes 0.0.0.0
+		viaLoopback = manet.IsIPLoopback(c.LocalMultiaddr()) || manet.IsIPLoopback(c.RemoteMultiaddr())
+	}
 
 	// set listen addrs, get our latest addrs from Host.
 	laddrs := ids.Host.Addrs()
-	// Note: LocalMultiaddr is sometimes 0.0.0.0
-	viaLoopback := manet.IsIPLoopback(c.LocalMultiaddr()) || manet.IsIPLoopback(c.RemoteMultiaddr())
 	mes.ListenAddrs = make([][]byte, 0, len(laddrs))
 	for _, addr := range laddrs {
 		if !viaLoopback && manet.IsIPLoopback(addr) {
@@ -381,7 +445,9 @@ func (ids *IDService) populateMessage(mes *pb.Identify, c network.Conn) {
 		}
 		mes.ListenAddrs = append(mes.ListenAddrs, addr.Bytes())
 	}
-	log.Debugf("%s sent listen addrs to %s: %s", c.LocalPeer(), c.RemotePeer(), laddrs)
+	if c != nil {
+		log.Debugf("%s sent listen addrs to %s: %s", c.LocalPeer(), c.RemotePeer(), laddrs)
+	}
 
 	// set our public key
 	ownKey := ids.Host.Peerstore().PubKey(ids.Host.ID())
@@ -409,11 +475,109 @@ func (ids *IDService) populateMessage(mes *pb.Identify, c network.Conn) {
 	av := ids.UserAgent
 	mes.ProtocolVersion = &pv
 	mes.AgentVersion = &av
+
+	// sign the fields above so the receiver can detect tampering or
+	// impersonation; see verifyIdentifySignature.
+	if sk := ids.Host.Peerstore().PrivKey(ids.Host.ID()); sk != nil {
+		sig, err := sk.Sign(signedIdentifyFields(mes))
+		if err != nil {
+			log.Errorf("failed to sign identify message: %s", err)
+		} else {
+			mes.Signature = sig
+		}
+	}
+}
+
+// signedIdentifyFields returns a canonical, deterministic encoding of the
+// fields of mes that are covered by its signature. ObservedAddr is
+// deliberately excluded, since it's specific to the connection the message
+// is sent over and would make the signature unverifiable by anyone else.
+func signedIdentifyFields(mes *pb.Identify) []byte {
+	var buf bytes.Buffer
+	for _, p := range mes.Protocols {
+		buf.WriteString(p)
+		buf.WriteByte(0)
+	}
+	for _, a := range mes.ListenAddrs {
+		buf.Write(a)
+		buf.WriteByte(0)
+	}
+	buf.WriteString(mes.GetProtocolVersion())
+	buf.WriteByte(0)
+	buf.WriteString(mes.GetAgentVersion())
+	buf.WriteByte(0)
+	for _, t := range mes.Transports {
+		buf.WriteString(t)
+		buf.WriteByte(0)
+	}
+	for _, sec := range mes.SecurityProtocols {
+		buf.WriteString(sec)
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+// errIdentifySignatureMissing and errIdentifySignatureInvalid are used as
+// EvtPeerIdentificationFailed.Reason values, so operators can tell a bad
+// signature apart from a dropped connection or protocol mismatch.
+var (
+	errIdentifySignatureMissing = errors.New("identify: message is missing a required signature")
+	errIdentifySignatureInvalid = errors.New("identify: message signature does not verify")
+)
+
+// verifyIdentifySignature checks mes's signature against the sender's
+// public key, which may come from the peerstore or from mes.PublicKey
+// itself. If ids.requireSignedIdentify is false, a missing signature (or
+// missing key to check it with) is tolerated for compatibility with peers
+// that don't sign Identify messages yet.
+func (ids *IDService) verifyIdentifySignature(mes *pb.Identify, p peer.ID) error {
+	pk := ids.Host.Peerstore().PubKey(p)
+	if pk == nil && len(mes.PublicKey) > 0 {
+		if unmarshaled, err := ic.UnmarshalPublicKey(mes.PublicKey); err == nil {
+			pk = unmarshaled
+		}
+	}
+	return verifySignedIdentifyFields(mes, pk, ids.requireSignedIdentify)
 }
 
-func (ids *IDService) consumeMessage(mes *pb.Identify, c network.Conn) {
+// verifySignedIdentifyFields is the key-agnostic core of
+// verifyIdentifySignature, split out so it can be tested with a plain
+// ic.PubKey instead of a full Host/Peerstore.
+func verifySignedIdentifyFields(mes *pb.Identify, pk ic.PubKey, requireSignedIdentify bool) error {
+	sig := mes.GetSignature()
+	if len(sig) == 0 {
+		if requireSignedIdentify {
+			return errIdentifySignatureMissing
+		}
+		return nil
+	}
+
+	if pk == nil {
+		if requireSignedIdentify {
+			return errIdentifySignatureMissing
+		}
+		return nil
+	}
+
+	ok, err := pk.Verify(signedIdentifyFields(mes), sig)
+	if err != nil || !ok {
+		return errIdentifySignatureInvalid
+	}
+	return nil
+}
+
+// consumeMessage verifies mes's signature and, if it checks out, applies
+// mes to our peerstore. It does not emit EvtPeerIdentificationFailed itself on
+// verification failure: that's left to the caller, so a single failure is
+// reported exactly once regardless of which path (identifyConn, pushHandler,
+// ...) invoked it.
+func (ids *IDService) consumeMessage(mes *pb.Identify, c network.Conn) error {
 	p := c.RemotePeer()
 
+	if err := ids.verifyIdentifySignature(mes, p); err != nil {
+		return err
+	}
+
 	// mes.Protocols
 	ids.Host.Peerstore().SetProtocols(p, mes.Protocols...)
 
@@ -465,8 +629,17 @@ func (ids *IDService) consumeMessage(mes *pb.Identify, c network.Conn) {
 	ids.Host.Peerstore().Put(p, "ProtocolVersion", pv)
 	ids.Host.Peerstore().Put(p, "AgentVersion", av)
 
+	// mes.Transports, mes.SecurityProtocols
+	if ts := mes.GetTransports(); len(ts) > 0 {
+		ids.Host.Peerstore().Put(p, transportsKey, ts)
+	}
+	if ss := mes.GetSecurityProtocols(); len(ss) > 0 {
+		ids.Host.Peerstore().Put(p, securityKey, ss)
+	}
+
 	// get the key from the other side. we may not have it (no-auth transport)
 	ids.consumeReceivedPubKey(c, mes.PublicKey)
+	return nil
 }
 
 func (ids *IDService) consumeReceivedPubKey(c network.Conn, kb []byte) {
@@ -583,9 +756,72 @@ func (ids *IDService) consumeObservedAddress(observed []byte, c network.Conn) {
 		return
 	}
 
+	if ids.strictObservedAddrs && !ids.localMultiaddrIsListenAddr(c.LocalMultiaddr()) {
+		atomic.AddUint64(&ids.numObservedAddrsDropped, 1)
+		log.Debugw("dropping observed address reported over connection whose local endpoint "+
+			"doesn't match any of our interface listen addresses",
+			"local", c.LocalMultiaddr(), "remote", c.RemotePeer())
+		return
+	}
+
 	ids.observedAddrs.Record(c, maddr)
 }
 
+// localMultiaddrIsListenAddr reports whether local is (as far as we can
+// tell) the local endpoint of a connection accepted on one of the host's
+// interface listen addresses, as opposed to an ephemeral outbound dial.
+// Matching transport family alone isn't enough to tell these apart on a
+// single-transport host: an outbound dial's LocalMultiaddr always shares
+// the listen address's protocol stack, it just binds an unrelated
+// ephemeral port. A connection accepted on a listening socket keeps that
+// socket's port as its local port, so comparing ports (in addition to
+// transport family) is what actually distinguishes the two cases.
+func (ids *IDService) localMultiaddrIsListenAddr(local ma.Multiaddr) bool {
+	listenAddrs, err := ids.Host.Network().InterfaceListenAddresses()
+	if err != nil {
+		log.Debugw("failed to fetch interface listen addresses", "error", err)
+		return true
+	}
+
+	localPort, ok := multiaddrPort(local)
+	if !ok {
+		// No TCP/UDP port to compare (e.g. a unix socket transport):
+		// fall back to a transport-family comparison.
+		return HasConsistentTransport(local, listenAddrs)
+	}
+
+	for _, la := range listenAddrs {
+		laPort, ok := multiaddrPort(la)
+		if !ok || laPort != localPort {
+			continue
+		}
+		if HasConsistentTransport(local, []ma.Multiaddr{la}) {
+			return true
+		}
+	}
+	return false
+}
+
+// multiaddrPort returns the TCP or UDP port component of addr, if it has
+// one.
+func multiaddrPort(addr ma.Multiaddr) (string, bool) {
+	for _, p := range addr.Protocols() {
+		if p.Code == ma.P_TCP || p.Code == ma.P_UDP {
+			if v, err := addr.ValueForProtocol(p.Code); err == nil {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// NumObservedAddrsDropped returns the number of observed addresses that have
+// been dropped by the strictObservedAddrs filter, for operators diagnosing
+// NAT-detection issues.
+func (ids *IDService) NumObservedAddrsDropped() uint64 {
+	return atomic.LoadUint64(&ids.numObservedAddrsDropped)
+}
+
 func addrInAddrs(a ma.Multiaddr, as []ma.Multiaddr) bool {
 	for _, b := range as {
 		if a.Equal(b) {
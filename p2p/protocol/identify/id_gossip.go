@@ -0,0 +1,199 @@
+package identify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+
+	pb "github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// gossipUpdateInterval is the minimum time a single peer must wait between
+// two Identify updates accepted over the gossip topic, to keep a noisy or
+// malicious peer from flooding it.
+const gossipUpdateInterval = 5 * time.Second
+
+// gossipLastUpdateTTL bounds how long we remember a peer's last accepted
+// gossip update, so g.lastUpdate doesn't grow without bound as a
+// long-running node sees churn across many peers.
+const gossipLastUpdateTTL = 10 * time.Minute
+
+// gossipIdentify holds the state needed to publish and consume Identify
+// updates over a pubsub topic, configured via WithGossipIdentify.
+type gossipIdentify struct {
+	topic *pubsub.Topic
+	sub   *pubsub.Subscription
+
+	lastUpdateMu sync.Mutex
+	lastUpdate   map[peer.ID]time.Time
+	lastSweep    time.Time
+}
+
+// setupGossipIdentify joins cfg's gossip topic (if configured), registers
+// a validator, and starts the goroutine that consumes updates published by
+// other peers. It returns nil if gossip wasn't configured.
+func setupGossipIdentify(ids *IDService, cfg *config) (*gossipIdentify, error) {
+	if cfg.gossipPS == nil {
+		return nil, nil
+	}
+
+	g := &gossipIdentify{lastUpdate: make(map[peer.ID]time.Time)}
+
+	if err := cfg.gossipPS.RegisterTopicValidator(cfg.gossipTopic, g.validate(ids)); err != nil {
+		return nil, err
+	}
+
+	topic, err := cfg.gossipPS.Join(cfg.gossipTopic)
+	if err != nil {
+		return nil, err
+	}
+	g.topic = topic
+
+	sub, err := topic.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+	g.sub = sub
+
+	ids.refCount.Add(1)
+	go ids.gossipIdentifyLoop(g)
+
+	return g, nil
+}
+
+// validate rejects updates from peers we aren't currently connected to (we
+// have no other way to trust who "from" is) and rate-limits accepted
+// updates per-peer, so a single noisy or malicious peer can't flood the
+// topic. Our own publications also pass through here and are accepted
+// without either check.
+func (g *gossipIdentify) validate(ids *IDService) pubsub.ValidatorEx {
+	return func(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+		p, err := peer.IDFromBytes(msg.GetFrom())
+		if err != nil {
+			return pubsub.ValidationReject
+		}
+		if p == ids.Host.ID() {
+			return pubsub.ValidationAccept
+		}
+		if ids.Host.Network().Connectedness(p) != network.Connected {
+			return pubsub.ValidationReject
+		}
+
+		if !g.allowUpdate(p, time.Now()) {
+			return pubsub.ValidationIgnore
+		}
+		return pubsub.ValidationAccept
+	}
+}
+
+// allowUpdate reports whether an update from p at time now should be
+// accepted, rate-limiting each peer to at most one accepted update per
+// gossipUpdateInterval. It also prunes g.lastUpdate of entries older than
+// gossipLastUpdateTTL, at most once per TTL window so cleanup cost scales
+// with time elapsed rather than with every call.
+func (g *gossipIdentify) allowUpdate(p peer.ID, now time.Time) bool {
+	g.lastUpdateMu.Lock()
+	defer g.lastUpdateMu.Unlock()
+
+	if now.Sub(g.lastSweep) > gossipLastUpdateTTL {
+		for pid, last := range g.lastUpdate {
+			if now.Sub(last) > gossipLastUpdateTTL {
+				delete(g.lastUpdate, pid)
+			}
+		}
+		g.lastSweep = now
+	}
+
+	if last, ok := g.lastUpdate[p]; ok && now.Sub(last) < gossipUpdateInterval {
+		return false
+	}
+	g.lastUpdate[p] = now
+	return true
+}
+
+// gossipIdentifyLoop consumes Identify updates published on the gossip
+// topic and folds them into the peerstore.
+func (ids *IDService) gossipIdentifyLoop(g *gossipIdentify) {
+	defer ids.refCount.Done()
+	defer g.sub.Cancel()
+
+	for {
+		msg, err := g.sub.Next(ids.ctx)
+		if err != nil {
+			return
+		}
+
+		p, err := peer.IDFromBytes(msg.GetFrom())
+		if err != nil || p == ids.Host.ID() {
+			continue
+		}
+
+		mes := &pb.Identify{}
+		if err := mes.Unmarshal(msg.Data); err != nil {
+			log.Debugf("error unmarshalling gossiped identify message from %s: %s", p, err)
+			continue
+		}
+
+		if err := ids.consumeGossipedMessage(mes, p); err != nil {
+			log.Debugf("rejected gossiped identify message from %s: %s", p, err)
+		}
+	}
+}
+
+// consumeGossipedMessage is the "connectionless" counterpart of
+// consumeMessage: it's fed an Identify message that didn't arrive over a
+// network.Conn, so it only ever updates peerstore protocols and addresses.
+// It never touches observed addresses, since there's no connection to
+// observe a source address on.
+func (ids *IDService) consumeGossipedMessage(mes *pb.Identify, p peer.ID) error {
+	if err := ids.verifyIdentifySignature(mes, p); err != nil {
+		ids.emitters.evtPeerIdentificationFailed.Emit(event.EvtPeerIdentificationFailed{Peer: p, Reason: err})
+		return err
+	}
+
+	ids.Host.Peerstore().SetProtocols(p, mes.Protocols...)
+
+	laddrs := mes.GetListenAddrs()
+	lmaddrs := make([]ma.Multiaddr, 0, len(laddrs))
+	for _, addr := range laddrs {
+		maddr, err := ma.NewMultiaddrBytes(addr)
+		if err != nil {
+			log.Debugf("failed to parse gossiped multiaddr from %s: %s", p, err)
+			continue
+		}
+		lmaddrs = append(lmaddrs, maddr)
+	}
+	ids.Host.Peerstore().AddAddrs(p, lmaddrs, peerstore.RecentlyConnectedAddrTTL)
+
+	return nil
+}
+
+// publishGossipIdentify marshals and publishes mes on the gossip topic, if gossip is
+// configured. Failures are logged, not returned, since gossip is always a
+// supplement to (never a replacement for) the per-connection push.
+func (ids *IDService) publishGossipIdentify(mes *pb.Identify) {
+	if ids.gossip == nil {
+		return
+	}
+
+	data, err := mes.Marshal()
+	if err != nil {
+		log.Debugf("failed to marshal identify message for gossip: %s", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ids.ctx, 10*time.Second)
+	defer cancel()
+	if err := ids.gossip.topic.Publish(ctx, data); err != nil {
+		log.Debugf("failed to publish identify message to gossip topic: %s", err)
+	}
+}
@@ -0,0 +1,81 @@
+package identify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/test"
+)
+
+func TestGossipIdentifyAllowUpdateRateLimits(t *testing.T) {
+	p, err := test.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &gossipIdentify{lastUpdate: make(map[peer.ID]time.Time)}
+	now := time.Now()
+
+	if !g.allowUpdate(p, now) {
+		t.Fatal("first update from a peer should be allowed")
+	}
+	if g.allowUpdate(p, now.Add(gossipUpdateInterval/2)) {
+		t.Fatal("update within gossipUpdateInterval should be rate-limited")
+	}
+	if !g.allowUpdate(p, now.Add(gossipUpdateInterval+time.Second)) {
+		t.Fatal("update after gossipUpdateInterval has elapsed should be allowed")
+	}
+}
+
+func TestGossipIdentifyAllowUpdatePerPeer(t *testing.T) {
+	p1, err := test.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := test.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &gossipIdentify{lastUpdate: make(map[peer.ID]time.Time)}
+	now := time.Now()
+
+	if !g.allowUpdate(p1, now) {
+		t.Fatal("first update from p1 should be allowed")
+	}
+	if !g.allowUpdate(p2, now) {
+		t.Fatal("rate limiting should be per-peer: p2's first update should be allowed")
+	}
+}
+
+func TestGossipIdentifyAllowUpdatePrunesStaleEntries(t *testing.T) {
+	p, err := test.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := test.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &gossipIdentify{lastUpdate: make(map[peer.ID]time.Time)}
+	now := time.Now()
+
+	g.allowUpdate(stale, now)
+	// Force a sweep well past gossipLastUpdateTTL; stale's entry is older
+	// than the TTL, so it should be pruned even though stale sent nothing
+	// new.
+	later := now.Add(gossipLastUpdateTTL + time.Second)
+	g.allowUpdate(p, later)
+
+	g.lastUpdateMu.Lock()
+	defer g.lastUpdateMu.Unlock()
+	if _, ok := g.lastUpdate[stale]; ok {
+		t.Fatal("expected stale's entry to be pruned by the sweep")
+	}
+	if _, ok := g.lastUpdate[p]; !ok {
+		t.Fatal("expected p's entry to be refreshed by the latest allowed update")
+	}
+}
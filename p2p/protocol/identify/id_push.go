@@ -0,0 +1,157 @@
+package identify
+
+import (
+	"bytes"
+
+	ic "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/helpers"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+
+	pb "github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+
+	ggio "github.com/gogo/protobuf/io"
+)
+
+// IDPush is the protocol.ID of the Identify push protocol. It carries a
+// full Identify message pushed unprompted by a peer whose protocols or
+// addresses changed. This package handles incoming pushes (pushHandler);
+// it does not send any itself.
+const IDPush = "/ipfs/id/push/1.0.0"
+
+// IDDelta is the protocol.ID of the Identify delta protocol: a lightweight
+// alternative to IDPush that carries only the protocols added or removed
+// since the last update.
+const IDDelta = "/p2p/id/delta/1.0.0"
+
+// pushHandler handles an incoming full Identify message pushed by a peer
+// whose protocols or addresses changed.
+func (ids *IDService) pushHandler(s network.Stream) {
+	c := s.Conn()
+	if err := ids.responseHandler(s); err != nil {
+		ids.emitters.evtPeerIdentificationFailed.Emit(event.EvtPeerIdentificationFailed{Peer: c.RemotePeer(), Reason: err})
+	}
+}
+
+// deltaHandler handles an incoming protocol delta pushed by a peer whose
+// protocols changed.
+func (ids *IDService) deltaHandler(s network.Stream) {
+	c := s.Conn()
+
+	r := ggio.NewDelimitedReader(s, 2048)
+	var delta pb.Delta
+	if err := r.ReadMsg(&delta); err != nil {
+		log.Warning("error reading identify delta message: ", err)
+		s.Reset()
+		return
+	}
+
+	p := c.RemotePeer()
+
+	if err := ids.verifyDeltaSignature(&delta, p); err != nil {
+		ids.emitters.evtPeerIdentificationFailed.Emit(event.EvtPeerIdentificationFailed{Peer: p, Reason: err})
+		log.Debugf("%s rejected delta from %s: %s", IDDelta, p, err)
+		s.Reset()
+		return
+	}
+
+	added := protocol.ConvertFromStrings(delta.GetAddedProtocols())
+	removed := protocol.ConvertFromStrings(delta.GetRmProtocols())
+
+	ids.Host.Peerstore().AddProtocols(p, delta.GetAddedProtocols()...)
+	ids.Host.Peerstore().RemoveProtocols(p, delta.GetRmProtocols()...)
+
+	ids.emitters.evtPeerProtocolsUpdated.Emit(event.EvtPeerProtocolsUpdated{
+		Peer:    p,
+		Added:   added,
+		Removed: removed,
+	})
+
+	go helpers.FullClose(s)
+}
+
+// fireProtocolDelta is called whenever our own protocol handlers change.
+// It pushes a signed delta over IDDelta to every connected peer; there is
+// no IDPush fallback, so peers that don't support IDDelta don't receive
+// the update this way.
+func (ids *IDService) fireProtocolDelta(evt event.EvtLocalProtocolsUpdated) {
+	if len(evt.Added) == 0 && len(evt.Removed) == 0 {
+		return
+	}
+
+	mes := &pb.Delta{
+		AddedProtocols: protocol.ConvertToStrings(evt.Added),
+		RmProtocols:    protocol.ConvertToStrings(evt.Removed),
+	}
+
+	if sk := ids.Host.Peerstore().PrivKey(ids.Host.ID()); sk != nil {
+		sig, err := sk.Sign(signedDeltaFields(mes))
+		if err != nil {
+			log.Errorf("failed to sign identify delta: %s", err)
+		} else {
+			mes.Signature = sig
+		}
+	}
+
+	ids.broadcast(IDDelta, func(s network.Stream) {
+		w := ggio.NewDelimitedWriter(s)
+		w.WriteMsg(mes)
+	})
+
+	if ids.gossip != nil {
+		full := &pb.Identify{}
+		ids.populateMessage(full, nil)
+		ids.publishGossipIdentify(full)
+	}
+}
+
+// signedDeltaFields returns a canonical, deterministic encoding of the
+// fields of d that are covered by its signature.
+func signedDeltaFields(d *pb.Delta) []byte {
+	var buf bytes.Buffer
+	for _, p := range d.GetAddedProtocols() {
+		buf.WriteString(p)
+		buf.WriteByte(0)
+	}
+	buf.WriteByte('|')
+	for _, p := range d.GetRmProtocols() {
+		buf.WriteString(p)
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+// verifyDeltaSignature checks d's signature against the sender's public
+// key, known from a prior Identify exchange. See verifyIdentifySignature
+// for the equivalent check on full Identify/Push messages.
+func (ids *IDService) verifyDeltaSignature(d *pb.Delta, p peer.ID) error {
+	return verifySignedDeltaFields(d, ids.Host.Peerstore().PubKey(p), ids.requireSignedIdentify)
+}
+
+// verifySignedDeltaFields is the key-agnostic core of
+// verifyDeltaSignature, split out so it can be tested with a plain
+// ic.PubKey instead of a full Host/Peerstore.
+func verifySignedDeltaFields(d *pb.Delta, pk ic.PubKey, requireSignedIdentify bool) error {
+	sig := d.GetSignature()
+	if len(sig) == 0 {
+		if requireSignedIdentify {
+			return errIdentifySignatureMissing
+		}
+		return nil
+	}
+
+	if pk == nil {
+		if requireSignedIdentify {
+			return errIdentifySignatureMissing
+		}
+		return nil
+	}
+
+	ok, err := pk.Verify(signedDeltaFields(d), sig)
+	if err != nil || !ok {
+		return errIdentifySignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package identify
+
+import (
+	"crypto/rand"
+	"testing"
+
+	ic "github.com/libp2p/go-libp2p-core/crypto"
+
+	pb "github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+)
+
+func mustTestKeyPair(t *testing.T) (ic.PrivKey, ic.PubKey) {
+	t.Helper()
+	sk, pk, err := ic.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating test key pair: %s", err)
+	}
+	return sk, pk
+}
+
+func signedTestIdentify(t *testing.T, sk ic.PrivKey) *pb.Identify {
+	t.Helper()
+	pv := "ipfs/0.1.0"
+	av := "test-agent/1.0"
+	mes := &pb.Identify{
+		ProtocolVersion: &pv,
+		AgentVersion:    &av,
+		Protocols:       []string{"/foo/1.0"},
+		ListenAddrs:     [][]byte{[]byte("addr1")},
+	}
+	sig, err := sk.Sign(signedIdentifyFields(mes))
+	if err != nil {
+		t.Fatalf("signing test identify message: %s", err)
+	}
+	mes.Signature = sig
+	return mes
+}
+
+func TestVerifySignedIdentifyFieldsValid(t *testing.T) {
+	sk, pk := mustTestKeyPair(t)
+	mes := signedTestIdentify(t, sk)
+
+	if err := verifySignedIdentifyFields(mes, pk, true); err != nil {
+		t.Errorf("expected valid signature to verify, got: %s", err)
+	}
+}
+
+func TestVerifySignedIdentifyFieldsTamperedField(t *testing.T) {
+	sk, pk := mustTestKeyPair(t)
+	mes := signedTestIdentify(t, sk)
+
+	mes.Protocols = append(mes.Protocols, "/evil/1.0")
+
+	if err := verifySignedIdentifyFields(mes, pk, true); err != errIdentifySignatureInvalid {
+		t.Errorf("expected errIdentifySignatureInvalid for a tampered field, got: %v", err)
+	}
+}
+
+func TestVerifySignedIdentifyFieldsMissingSignature(t *testing.T) {
+	_, pk := mustTestKeyPair(t)
+	mes := &pb.Identify{Protocols: []string{"/foo/1.0"}}
+
+	if err := verifySignedIdentifyFields(mes, pk, true); err != errIdentifySignatureMissing {
+		t.Errorf("expected errIdentifySignatureMissing when requireSignedIdentify is set, got: %v", err)
+	}
+	if err := verifySignedIdentifyFields(mes, pk, false); err != nil {
+		t.Errorf("expected a missing signature to be tolerated when requireSignedIdentify is unset, got: %s", err)
+	}
+}
+
+func TestVerifySignedIdentifyFieldsNoKey(t *testing.T) {
+	sk, _ := mustTestKeyPair(t)
+	mes := signedTestIdentify(t, sk)
+
+	if err := verifySignedIdentifyFields(mes, nil, true); err != errIdentifySignatureMissing {
+		t.Errorf("expected errIdentifySignatureMissing with no key to verify against, got: %v", err)
+	}
+	if err := verifySignedIdentifyFields(mes, nil, false); err != nil {
+		t.Errorf("expected a missing key to be tolerated when requireSignedIdentify is unset, got: %s", err)
+	}
+}
+
+func TestVerifySignedDeltaFieldsValid(t *testing.T) {
+	sk, pk := mustTestKeyPair(t)
+	d := &pb.Delta{AddedProtocols: []string{"/a"}, RmProtocols: []string{"/b"}}
+	sig, err := sk.Sign(signedDeltaFields(d))
+	if err != nil {
+		t.Fatalf("signing test delta message: %s", err)
+	}
+	d.Signature = sig
+
+	if err := verifySignedDeltaFields(d, pk, true); err != nil {
+		t.Errorf("expected valid delta signature to verify, got: %s", err)
+	}
+}
+
+func TestVerifySignedDeltaFieldsTampered(t *testing.T) {
+	sk, pk := mustTestKeyPair(t)
+	d := &pb.Delta{AddedProtocols: []string{"/a"}}
+	sig, err := sk.Sign(signedDeltaFields(d))
+	if err != nil {
+		t.Fatalf("signing test delta message: %s", err)
+	}
+	d.Signature = sig
+
+	d.RmProtocols = []string{"/evil"}
+
+	if err := verifySignedDeltaFields(d, pk, true); err != errIdentifySignatureInvalid {
+		t.Errorf("expected errIdentifySignatureInvalid for a tampered delta, got: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package identify
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// transportsKey and securityKey are the peerstore.Put/Get keys the
+// Transports and SecurityProtocols fields of a remote peer's Identify
+// message are stored under.
+const (
+	transportsKey = "Transports"
+	securityKey   = "Security"
+)
+
+// transportLister is implemented by networks that can enumerate the
+// transport protocols they support listening on or dialing out over (e.g.
+// swarm.Swarm), beyond what we can infer from our own listen addresses
+// (e.g. a client-only transport we dial but never listen on). It's checked
+// via a type-assertion on ids.Host.Network() rather than imported
+// directly, to avoid a dependency cycle with p2p/net/swarm. No type in
+// this tree implements it yet; until one does, localTransports() falls
+// back to deriving names from our own listen addresses, below.
+type transportLister interface {
+	Transports() []string
+}
+
+// securityLister is implemented by a host's security transport/upgrader
+// (e.g. "noise", "tls13"), which isn't something we can infer generically
+// the way we can transports (a multiaddr doesn't reliably encode which
+// security protocol will be negotiated over it). No type in this tree
+// implements it yet, so localSecurityProtocols() always returns nil until
+// one does; SecurityProtocols is still defined on the wire and signed so
+// that future hosts can report it without another protocol bump.
+type securityLister interface {
+	SecurityProtocols() []string
+}
+
+// localTransports returns the coarse names (e.g. "tcp", "quic", "ws") of
+// the transports this host supports. If its Network exposes a richer list
+// via transportLister that's used; otherwise the names are derived from
+// the host's own listen addresses.
+func (ids *IDService) localTransports() []string {
+	if tl, ok := ids.Host.Network().(transportLister); ok {
+		return tl.Transports()
+	}
+	return transportNamesForAddrs(ids.Host.Addrs())
+}
+
+// localSecurityProtocols returns the security transport protocol ids (e.g.
+// "/noise", "/tls/1.0.0") this host supports, if its Host exposes them via
+// securityLister.
+func (ids *IDService) localSecurityProtocols() []string {
+	if sl, ok := ids.Host.(securityLister); ok {
+		return sl.SecurityProtocols()
+	}
+	return nil
+}
+
+// transportNamesForAddrs returns the de-duplicated, ordered set of
+// transport names (see addrTransportName) found across addrs.
+func transportNamesForAddrs(addrs []ma.Multiaddr) []string {
+	seen := make(map[string]struct{}, len(addrs))
+	names := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		name := addrTransportName(a)
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names
+}
+
+// RemoteTransports returns the transport names peer p reported supporting
+// in its Identify message, or nil if we've never identified p or it didn't
+// report any.
+func (ids *IDService) RemoteTransports(p peer.ID) []string {
+	v, err := ids.Host.Peerstore().Get(p, transportsKey)
+	if err != nil {
+		return nil
+	}
+	ts, _ := v.([]string)
+	return ts
+}
+
+// RemoteSecurityProtocols returns the security protocol ids peer p
+// reported supporting in its Identify message, or nil if we've never
+// identified p or it didn't report any.
+func (ids *IDService) RemoteSecurityProtocols(p peer.ID) []string {
+	v, err := ids.Host.Peerstore().Get(p, securityKey)
+	if err != nil {
+		return nil
+	}
+	ss, _ := v.([]string)
+	return ss
+}
+
+// addrTransportName returns a coarse transport name for addr (e.g. "tcp",
+// "quic", "ws", "wss"), matching the vocabulary localTransports is
+// expected to report. The outermost match wins, so /tcp/.../ws is "ws". It
+// returns "" for anything else.
+func addrTransportName(addr ma.Multiaddr) string {
+	protos := addr.Protocols()
+	for i := len(protos) - 1; i >= 0; i-- {
+		p := protos[i]
+		switch p.Name {
+		case "quic", "ws", "wss", "tcp":
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// PreferredAddrs reorders addrs so that any whose transport appears in
+// peer p's self-reported Transports (see RemoteTransports) sort first,
+// preserving relative order otherwise.
+//
+// NOTE: this only implements the ranking logic, not the dial integration
+// the request asked for. p2p/net/swarm, which owns per-peer dial address
+// selection, isn't part of this tree, so there's no dialer here for
+// PreferredAddrs to plug into, and nothing in this package calls it. Treat
+// transport-preferred dialing as unimplemented until a swarm change adds a
+// call site for it; don't read this method's existence as that work being
+// done.
+func (ids *IDService) PreferredAddrs(p peer.ID, addrs []ma.Multiaddr) []ma.Multiaddr {
+	return sortAddrsByTransport(addrs, ids.RemoteTransports(p))
+}
+
+// sortAddrsByTransport implements the ranking PreferredAddrs exposes,
+// split out so it can be tested without a host/peerstore.
+func sortAddrsByTransport(addrs []ma.Multiaddr, preferredTransports []string) []ma.Multiaddr {
+	if len(preferredTransports) == 0 {
+		return addrs
+	}
+
+	supported := make(map[string]struct{}, len(preferredTransports))
+	for _, t := range preferredTransports {
+		supported[t] = struct{}{}
+	}
+
+	preferred := make([]ma.Multiaddr, 0, len(addrs))
+	var rest []ma.Multiaddr
+	for _, a := range addrs {
+		if _, ok := supported[addrTransportName(a)]; ok {
+			preferred = append(preferred, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return append(preferred, rest...)
+}
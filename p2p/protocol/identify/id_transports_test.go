@@ -0,0 +1,66 @@
+package identify
+
+import (
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func mustAddr(t *testing.T, s string) ma.Multiaddr {
+	t.Helper()
+	a, err := ma.NewMultiaddr(s)
+	if err != nil {
+		t.Fatalf("parsing multiaddr %q: %s", s, err)
+	}
+	return a
+}
+
+func TestAddrTransportName(t *testing.T) {
+	cases := []struct {
+		addr string
+		want string
+	}{
+		{"/ip4/1.2.3.4/tcp/4001", "tcp"},
+		{"/ip4/1.2.3.4/udp/4001/quic", "quic"},
+		{"/ip4/1.2.3.4/tcp/4001/ws", "ws"},
+		{"/ip4/1.2.3.4/udp/4001", ""},
+	}
+	for _, tc := range cases {
+		got := addrTransportName(mustAddr(t, tc.addr))
+		if got != tc.want {
+			t.Errorf("addrTransportName(%s) = %q, want %q", tc.addr, got, tc.want)
+		}
+	}
+}
+
+func TestSortAddrsByTransport(t *testing.T) {
+	tcp := mustAddr(t, "/ip4/1.2.3.4/tcp/4001")
+	quic := mustAddr(t, "/ip4/1.2.3.4/udp/4001/quic")
+	ws := mustAddr(t, "/ip4/1.2.3.4/tcp/4001/ws")
+	addrs := []ma.Multiaddr{tcp, quic, ws}
+
+	// No reported remote transports: order is left untouched.
+	if got := sortAddrsByTransport(addrs, nil); !addrsEqual(got, addrs) {
+		t.Errorf("sortAddrsByTransport with no remote transports reordered addrs: got %v", got)
+	}
+
+	// QUIC preferred: it should sort first, everything else keeps its
+	// relative order.
+	got := sortAddrsByTransport(addrs, []string{"quic"})
+	want := []ma.Multiaddr{quic, tcp, ws}
+	if !addrsEqual(got, want) {
+		t.Errorf("sortAddrsByTransport(quic preferred) = %v, want %v", got, want)
+	}
+}
+
+func addrsEqual(a, b []ma.Multiaddr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package identify
+
+import pubsub "github.com/libp2p/go-libp2p-pubsub"
+
+// config holds options that can be configured via NewIDService's variadic
+// Option arguments.
+type config struct {
+	userAgent string
+
+	// strictObservedAddrs governs whether observed addresses reported by
+	// remote peers are cross-checked against our own interface listen
+	// addresses before being recorded (see consumeObservedAddress). It
+	// defaults to true; set it to false to restore the old, unfiltered
+	// behaviour while diagnosing NAT-detection regressions.
+	strictObservedAddrs bool
+
+	// requireSignedIdentify governs whether an Identify message with no
+	// signature (or no known key to check it with) is rejected, rather than
+	// tolerated. A signature that is present and fails to verify is
+	// rejected either way. It defaults to false so that unsigned peers can
+	// still be identified during a rollout; operators that have confirmed
+	// their whole network signs Identify messages can tighten this.
+	requireSignedIdentify bool
+
+	// gossipPS and gossipTopic, when gossipPS is non-nil, make the
+	// IDService additionally publish and consume Identify updates over a
+	// pubsub topic instead of relying solely on per-connection pushes; see
+	// WithGossipIdentify.
+	gossipPS    *pubsub.PubSub
+	gossipTopic string
+}
+
+// Option is an option function for identify.
+type Option func(*config)
+
+// UserAgent sets the user agent this host will identify itself with to peers.
+func UserAgent(ua string) Option {
+	return func(cfg *config) {
+		cfg.userAgent = ua
+	}
+}
+
+// WithObservedAddrManagerStrictFiltering toggles strict filtering of
+// observed addresses. When strict (the default), an observed address is
+// only recorded if the local endpoint of the connection it was reported on
+// matches one of the host's own interface listen addresses (by transport
+// and port).
+// Disabling this restores the legacy behaviour of trusting every observed
+// address, which can let ephemeral outbound dials leak bogus "observed"
+// addresses into the advertised address set.
+func WithObservedAddrManagerStrictFiltering(strict bool) Option {
+	return func(cfg *config) {
+		cfg.strictObservedAddrs = strict
+	}
+}
+
+// WithRequireSignedIdentify makes the IDService reject any Identify, Push,
+// or Delta message that doesn't carry a valid signature (for Identify and
+// Push: over its ListenAddrs, Protocols, ProtocolVersion, AgentVersion,
+// Transports, and SecurityProtocols fields; for Delta: over its added and
+// removed protocols). Leave
+// this false (the default) while rolling out signed Identify across a
+// network that still has unsigned peers.
+func WithRequireSignedIdentify(require bool) Option {
+	return func(cfg *config) {
+		cfg.requireSignedIdentify = require
+	}
+}
+
+// WithGossipIdentify makes the IDService publish Identify protocol updates
+// to the given pubsub topic, in addition to the existing per-connection
+// IDPush/IDDelta broadcast, and consume updates published there by other
+// peers. The per-connection broadcast still runs, so this does not remove
+// its O(peers) cost; it requires a shared PubSub and topic name across
+// the network.
+func WithGossipIdentify(ps *pubsub.PubSub, topic string) Option {
+	return func(cfg *config) {
+		cfg.gossipPS = ps
+		cfg.gossipTopic = topic
+	}
+}